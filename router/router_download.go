@@ -2,13 +2,19 @@ package router
 
 import (
 	"bufio"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
+	"net/textproto"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -18,40 +24,77 @@ import (
 	"github.com/pterodactyl/wings/server/backup"
 )
 
-// Structure pour gérer les Range requests
+// maxRangesPerRequest caps how many ranges a single Range header can request
+// before we respond 416: beyond that, a client has no legitimate reason to
+// ask for that many segments and it becomes a resource-exhaustion vector.
+const maxRangesPerRequest = 20
+
+// maxRangeSpanMultiplier rejects a request outright, rather than building a
+// massive multipart response, once the sum of the requested ranges (after
+// merging overlapping/adjacent ones) exceeds this multiple of the file size.
+const maxRangeSpanMultiplier = 2
+
+// rangeSpec describes a single byte range to serve from a file.
 type rangeSpec struct {
 	start int64
 	end   int64
 	size  int64
 }
 
-// Parse le header Range selon RFC 7233
-func parseRangeHeader(rangeHeader string, fileSize int64) (*rangeSpec, error) {
+// parseRangeHeader parses a Range header per RFC 7233, handling multiple
+// comma-separated ranges. Individual ranges that aren't satisfiable are
+// dropped; if none are, an error is returned (416). Ranges that overlap or
+// touch are merged.
+func parseRangeHeader(rangeHeader string, fileSize int64) ([]rangeSpec, error) {
 	if rangeHeader == "" {
-		return nil, nil // Pas de range = fichier complet
+		return nil, nil // no range = full file
 	}
 
 	if !strings.HasPrefix(rangeHeader, "bytes=") {
-		return nil, errors.New("format range invalide")
+		return nil, errors.New("invalid range format")
 	}
 
-	// Parse "bytes=start-end" ou "bytes=start-" ou "bytes=-suffix"
-	ranges := strings.TrimPrefix(rangeHeader, "bytes=")
+	raw := strings.Split(strings.TrimPrefix(rangeHeader, "bytes="), ",")
+	if len(raw) > maxRangesPerRequest {
+		return nil, errors.New("too many ranges requested")
+	}
 
-	// On ne supporte qu'un seul range pour simplifier
-	if strings.Contains(ranges, ",") {
-		parts := strings.Split(ranges, ",")
-		ranges = strings.TrimSpace(parts[0]) // Prend juste le premier range
+	var ranges []rangeSpec
+	for _, part := range raw {
+		spec, ok := parseSingleRange(strings.TrimSpace(part), fileSize)
+		if ok {
+			ranges = append(ranges, spec)
+		}
 	}
+	if len(ranges) == 0 {
+		return nil, errors.New("no satisfiable range")
+	}
+
+	ranges = coalesceRanges(ranges)
 
+	var total int64
+	for _, r := range ranges {
+		total += r.size
+	}
+	if total > fileSize*maxRangeSpanMultiplier {
+		return nil, errors.New("total range span too large")
+	}
+
+	return ranges, nil
+}
+
+// parseSingleRange parses a single "start-end", "start-" or "-suffix" spec.
+// The returned bool reports whether this particular range is satisfiable.
+func parseSingleRange(spec string, fileSize int64) (rangeSpec, bool) {
 	var start, end int64
 	var err error
 
-	if strings.HasPrefix(ranges, "-") {
-		// Suffix range: "-500" = derniers 500 bytes
-		suffixLength, err := strconv.ParseInt(ranges[1:], 10, 64)
-		if err != nil {
-			return nil, errors.New("suffix range invalide")
+	switch {
+	case strings.HasPrefix(spec, "-"):
+		// Suffix range: "-500" = last 500 bytes
+		suffixLength, perr := strconv.ParseInt(spec[1:], 10, 64)
+		if perr != nil || suffixLength <= 0 {
+			return rangeSpec{}, false
 		}
 		if suffixLength >= fileSize {
 			start = 0
@@ -59,41 +102,191 @@ func parseRangeHeader(rangeHeader string, fileSize int64) (*rangeSpec, error) {
 			start = fileSize - suffixLength
 		}
 		end = fileSize - 1
-	} else if strings.HasSuffix(ranges, "-") {
-		// Start range: "500-" = du byte 500 à la fin
-		start, err = strconv.ParseInt(ranges[:len(ranges)-1], 10, 64)
+	case strings.HasSuffix(spec, "-"):
+		// Start range: "500-" = from byte 500 to the end
+		start, err = strconv.ParseInt(spec[:len(spec)-1], 10, 64)
 		if err != nil {
-			return nil, errors.New("start range invalide")
+			return rangeSpec{}, false
 		}
 		end = fileSize - 1
-	} else {
+	default:
 		// Full range: "500-999"
-		parts := strings.Split(ranges, "-")
+		parts := strings.SplitN(spec, "-", 2)
 		if len(parts) != 2 {
-			return nil, errors.New("format range invalide")
+			return rangeSpec{}, false
 		}
-
 		start, err = strconv.ParseInt(parts[0], 10, 64)
 		if err != nil {
-			return nil, errors.New("start range invalide")
+			return rangeSpec{}, false
 		}
-
 		end, err = strconv.ParseInt(parts[1], 10, 64)
 		if err != nil {
-			return nil, errors.New("end range invalide")
+			return rangeSpec{}, false
 		}
 	}
 
-	// Validation des limites
-	if start < 0 || start >= fileSize || end < start || end >= fileSize {
-		return nil, errors.New("range hors limites")
+	if start < 0 || start >= fileSize || end < start {
+		return rangeSpec{}, false
 	}
+	if end >= fileSize {
+		end = fileSize - 1
+	}
+
+	return rangeSpec{start: start, end: end, size: end - start + 1}, true
+}
+
+// coalesceRanges sorts ranges by start position and merges those that
+// overlap or are adjacent, so a multipart/byteranges response never sends
+// the same bytes twice.
+func coalesceRanges(ranges []rangeSpec) []rangeSpec {
+	if len(ranges) < 2 {
+		return ranges
+	}
+
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].start < ranges[j].start
+	})
+
+	merged := ranges[:1]
+	for _, r := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if r.start <= last.end+1 {
+			if r.end > last.end {
+				last.end = r.end
+				last.size = last.end - last.start + 1
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+
+	return merged
+}
 
-	return &rangeSpec{
-		start: start,
-		end:   end,
-		size:  end - start + 1,
-	}, nil
+// fileETag builds a strong ETag from a file's size, mtime and inode, for
+// clients that want to resume a transfer with a validator rather than
+// relying on mtime alone.
+func fileETag(st os.FileInfo) string {
+	var inode uint64
+	if sys, ok := st.Sys().(*syscall.Stat_t); ok {
+		inode = sys.Ino
+	}
+	return strconv.Quote(fmt.Sprintf("%x-%x-%x", st.Size(), st.ModTime().UnixNano(), inode))
+}
+
+// backupETag builds a strong ETag from a backup's checksum when one is
+// available, and falls back to fileETag otherwise.
+func backupETag(sum []byte, sumErr error, st os.FileInfo) string {
+	if sumErr == nil && len(sum) > 0 {
+		return strconv.Quote(hex.EncodeToString(sum))
+	}
+	return fileETag(st)
+}
+
+// ifRangeMatches reports whether an If-Range header (a strong etag or a
+// date) still validates the current resource. A weak etag ("W/...") or a
+// date that doesn't exactly match the emitted Last-Modified never matches,
+// which falls the caller back to a full 200 response.
+func ifRangeMatches(header string, etag string, modTime time.Time) bool {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return true
+	}
+	if strings.HasPrefix(header, "W/") {
+		return false
+	}
+	if strings.HasPrefix(header, "\"") {
+		return etag != "" && header == etag
+	}
+	t, err := http.ParseTime(header)
+	if err != nil {
+		return false
+	}
+	return t.Equal(modTime.Truncate(time.Second))
+}
+
+// writeRangedResponse emits the appropriate Range response (a single 206, a
+// multipart/byteranges 206, or a full 200) for the already-open file f.
+func writeRangedResponse(c *gin.Context, f *os.File, fileSize int64, ranges []rangeSpec, bufferSizeFor func(int64) int) {
+	switch len(ranges) {
+	case 0:
+		// Full file (200), streamed through a size-tiered buffer.
+		c.Header("Content-Length", strconv.FormatInt(fileSize, 10))
+		c.Status(http.StatusOK)
+
+		bufferedReader := bufio.NewReaderSize(f, bufferSizeFor(fileSize))
+		if _, err := bufferedReader.WriteTo(c.Writer); err != nil && err != io.EOF {
+			// Log without aborting; the client likely just closed the connection.
+			middleware.ExtractLogger(c).WithError(err).Debug("error streaming download (connection likely closed)")
+		}
+	case 1:
+		// Partial Content (206)
+		r := ranges[0]
+		c.Header("Content-Range", fmt.Sprintf("bytes %d-%d/%d", r.start, r.end, fileSize))
+		c.Header("Content-Length", strconv.FormatInt(r.size, 10))
+		c.Status(http.StatusPartialContent)
+
+		if _, err := f.Seek(r.start, io.SeekStart); err != nil {
+			middleware.CaptureAndAbort(c, err)
+			return
+		}
+		if _, err := io.CopyN(c.Writer, f, r.size); err != nil && err != io.EOF {
+			middleware.ExtractLogger(c).WithError(err).Debug("error streaming partial range (connection likely closed)")
+		}
+	default:
+		// Partial Content (206) multipart/byteranges: no Content-Length can be
+		// known ahead of time, so the response goes out chunked.
+		mw := multipart.NewWriter(c.Writer)
+		c.Header("Content-Type", "multipart/byteranges; boundary="+mw.Boundary())
+		c.Status(http.StatusPartialContent)
+
+		for _, r := range ranges {
+			pw, err := mw.CreatePart(textproto.MIMEHeader{
+				"Content-Type":  {"application/octet-stream"},
+				"Content-Range": {fmt.Sprintf("bytes %d-%d/%d", r.start, r.end, fileSize)},
+			})
+			if err != nil {
+				middleware.ExtractLogger(c).WithError(err).Debug("error building multipart/byteranges")
+				return
+			}
+			if _, err := f.Seek(r.start, io.SeekStart); err != nil {
+				middleware.ExtractLogger(c).WithError(err).Debug("error seeking for multipart/byteranges")
+				return
+			}
+			if _, err := io.CopyN(pw, f, r.size); err != nil && err != io.EOF {
+				middleware.ExtractLogger(c).WithError(err).Debug("error streaming multipart part (connection likely closed)")
+				return
+			}
+		}
+		if err := mw.Close(); err != nil && err != io.EOF {
+			middleware.ExtractLogger(c).WithError(err).Debug("error closing multipart/byteranges")
+		}
+	}
+}
+
+// downloadBufferSizeDefault, backupBufferSizeLarge and fileBufferSizeLarge
+// are the buffer tiers handed to bufio for the full-file download path.
+// downloadLimiterMinBurst (router_download_limits.go) must stay >= the
+// largest of these, since the rate limiter's burst needs to cover the
+// biggest single Write() it can be asked to pace.
+const (
+	downloadBufferSizeDefault = 64 * 1024       // 64KB by default
+	backupBufferSizeLarge     = 2 * 1024 * 1024 // 2MB for large backups
+	fileBufferSizeLarge       = 1024 * 1024     // 1MB for large files
+)
+
+func backupBufferSize(fileSize int64) int {
+	if fileSize > 1024*1024*1024 { // > 1GB
+		return backupBufferSizeLarge
+	}
+	return downloadBufferSizeDefault
+}
+
+func fileBufferSize(fileSize int64) int {
+	if fileSize > 100*1024*1024 { // > 100MB
+		return fileBufferSizeLarge
+	}
+	return downloadBufferSizeDefault
 }
 
 // Handle HEAD request for server backup
@@ -143,12 +336,15 @@ func getDownloadBackupHead(c *gin.Context) {
 	}
 	defer f.Close()
 
-	// Headers standards + Range support
+	sum, sumErr := b.Checksum()
+
+	// Standard headers, plus Range support
 	c.Header("Content-Length", strconv.FormatInt(st.Size(), 10))
 	c.Header("Content-Disposition", "attachment; filename="+strconv.Quote(st.Name()))
 	c.Header("Content-Type", "application/octet-stream")
-	c.Header("Accept-Ranges", "bytes") //Support Range
+	c.Header("Accept-Ranges", "bytes")
 	c.Header("Last-Modified", st.ModTime().UTC().Format(http.TimeFormat))
+	c.Header("ETag", backupETag(sum, sumErr, st))
 
 	c.Status(http.StatusOK)
 }
@@ -173,6 +369,12 @@ func getDownloadBackup(c *gin.Context) {
 		return
 	}
 
+	release, ok := acquireDownloadSlot(c, token.ServerUuid)
+	if !ok {
+		return
+	}
+	defer release()
+
 	// Validate UUID
 	if _, err := uuid.Parse(token.BackupUuid); err != nil {
 		middleware.CaptureAndAbort(c, err)
@@ -201,59 +403,31 @@ func getDownloadBackup(c *gin.Context) {
 	defer f.Close()
 
 	fileSize := st.Size()
+	sum, sumErr := b.Checksum()
+	etag := backupETag(sum, sumErr, st)
 
-	// Parse Range header si présent
-	rangeHeader := c.GetHeader("Range")
-	rangeSpec, err := parseRangeHeader(rangeHeader, fileSize)
-
-	if err != nil {
-		// Range invalide
-		c.Header("Content-Range", fmt.Sprintf("bytes */%d", fileSize))
-		c.AbortWithStatus(http.StatusRequestedRangeNotSatisfiable)
-		return
+	// Parse the Range header if present, honoring If-Range
+	var ranges []rangeSpec
+	if rangeHeader := c.GetHeader("Range"); rangeHeader != "" && ifRangeMatches(c.GetHeader("If-Range"), etag, st.ModTime()) {
+		ranges, err = parseRangeHeader(rangeHeader, fileSize)
+		if err != nil {
+			c.Header("Content-Range", fmt.Sprintf("bytes */%d", fileSize))
+			c.AbortWithStatus(http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
 	}
 
-	// Headers de base
+	// Base headers
 	c.Header("Content-Disposition", "attachment; filename="+strconv.Quote(st.Name()))
+	c.Header("Accept-Ranges", "bytes")
+	c.Header("Last-Modified", st.ModTime().UTC().Format(http.TimeFormat))
+	c.Header("ETag", etag)
+	// Set here for the full-file and single-range cases; only the multipart
+	// case (writeRangedResponse) overwrites this with its own value.
 	c.Header("Content-Type", "application/octet-stream")
-	c.Header("Accept-Ranges", "bytes") // SEULE NOUVEAUTÉ
-
-	if rangeSpec != nil {
-		// Partial Content (206) NOUVELLE FONCTIONNALITÉ
-		c.Header("Content-Range", fmt.Sprintf("bytes %d-%d/%d", rangeSpec.start, rangeSpec.end, fileSize))
-		c.Header("Content-Length", strconv.FormatInt(rangeSpec.size, 10))
-		c.Status(http.StatusPartialContent)
-
-		// Seek au début du range
-		if _, err := f.Seek(rangeSpec.start, io.SeekStart); err != nil {
-			middleware.CaptureAndAbort(c, err)
-			return
-		}
-
-		// Copy seulement la partie demandée
-		_, err := io.CopyN(c.Writer, f, rangeSpec.size)
-		if err != nil && err != io.EOF {
-			// Log l'erreur mais continue (connexion client fermée normale)
-			middleware.ExtractLogger(c).WithError(err).Debug("erreur streaming backup partiel (connexion probablement fermée)")
-		}
-	} else {
-		// Fichier complet (200) buffer optimisé
-		c.Header("Content-Length", strconv.FormatInt(fileSize, 10))
-		c.Status(http.StatusOK)
-
-		// Utilise un buffer optimisé au lieu de bufio.NewReader(f).WriteTo(c.Writer)
-		bufferSize := 64 * 1024        // 64KB par défaut
-		if fileSize > 1024*1024*1024 { // > 1GB
-			bufferSize = 2 * 1024 * 1024 // 2MB pour gros backups
-		}
 
-		bufferedReader := bufio.NewReaderSize(f, bufferSize)
-		_, err := bufferedReader.WriteTo(c.Writer)
-		if err != nil && err != io.EOF {
-			// Log sans abort
-			middleware.ExtractLogger(c).WithError(err).Debug("erreur streaming backup (connexion probablement fermée)")
-		}
-	}
+	defer applyTokenRateLimit(c, token)()
+	writeRangedResponse(c, f, fileSize, ranges, backupBufferSize)
 }
 
 // Handle HEAD request for server file
@@ -292,12 +466,13 @@ func getDownloadFileHead(c *gin.Context) {
 		return
 	}
 
-	// Headers standards + Range support
+	// Standard headers, plus Range support
 	c.Header("Content-Length", strconv.FormatInt(st.Size(), 10))
 	c.Header("Content-Disposition", "attachment; filename="+strconv.Quote(st.Name()))
 	c.Header("Content-Type", "application/octet-stream")
-	c.Header("Accept-Ranges", "bytes") // ← SEULE NOUVEAUTÉ : Support Range
+	c.Header("Accept-Ranges", "bytes")
 	c.Header("Last-Modified", st.ModTime().UTC().Format(http.TimeFormat))
+	c.Header("ETag", fileETag(st))
 
 	c.Status(http.StatusOK)
 }
@@ -322,6 +497,12 @@ func getDownloadFile(c *gin.Context) {
 		return
 	}
 
+	release, ok := acquireDownloadSlot(c, token.ServerUuid)
+	if !ok {
+		return
+	}
+	defer release()
+
 	// Get file
 	f, st, err := s.Filesystem().File(token.FilePath)
 	if err != nil {
@@ -339,57 +520,28 @@ func getDownloadFile(c *gin.Context) {
 	}
 
 	fileSize := st.Size()
+	etag := fileETag(st)
 
-	// Parse Range header si présent
-	rangeHeader := c.GetHeader("Range")
-	rangeSpec, err := parseRangeHeader(rangeHeader, fileSize)
-
-	if err != nil {
-		// Range invalide
-		c.Header("Content-Range", fmt.Sprintf("bytes */%d", fileSize))
-		c.AbortWithStatus(http.StatusRequestedRangeNotSatisfiable)
-		return
+	// Parse the Range header if present, honoring If-Range
+	var ranges []rangeSpec
+	if rangeHeader := c.GetHeader("Range"); rangeHeader != "" && ifRangeMatches(c.GetHeader("If-Range"), etag, st.ModTime()) {
+		ranges, err = parseRangeHeader(rangeHeader, fileSize)
+		if err != nil {
+			c.Header("Content-Range", fmt.Sprintf("bytes */%d", fileSize))
+			c.AbortWithStatus(http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
 	}
 
-	// Headers de base
+	// Base headers
 	c.Header("Content-Disposition", "attachment; filename="+strconv.Quote(st.Name()))
+	c.Header("Accept-Ranges", "bytes")
+	c.Header("Last-Modified", st.ModTime().UTC().Format(http.TimeFormat))
+	c.Header("ETag", etag)
+	// Set here for the full-file and single-range cases; only the multipart
+	// case (writeRangedResponse) overwrites this with its own value.
 	c.Header("Content-Type", "application/octet-stream")
-	c.Header("Accept-Ranges", "bytes") // SEULE NOUVEAUTÉ
 
-	if rangeSpec != nil {
-		// Partial Content (206) NOUVELLE FONCTIONNALITÉ
-		c.Header("Content-Range", fmt.Sprintf("bytes %d-%d/%d", rangeSpec.start, rangeSpec.end, fileSize))
-		c.Header("Content-Length", strconv.FormatInt(rangeSpec.size, 10))
-		c.Status(http.StatusPartialContent)
-
-		// Seek au début du range
-		if _, err := f.Seek(rangeSpec.start, io.SeekStart); err != nil {
-			middleware.CaptureAndAbort(c, err)
-			return
-		}
-
-		// Copy seulement la partie demandée
-		_, err := io.CopyN(c.Writer, f, rangeSpec.size)
-		if err != nil && err != io.EOF {
-			// Log l'erreur mais continue (connexion client fermée normale)
-			middleware.ExtractLogger(c).WithError(err).Debug("erreur streaming file partiel (connexion probablement fermée)")
-		}
-	} else {
-		// Fichier complet (200) buffer optimisé
-		c.Header("Content-Length", strconv.FormatInt(fileSize, 10))
-		c.Status(http.StatusOK)
-
-		// Utilise un buffer optimisé au lieu de bufio.NewReader(f).WriteTo(c.Writer)
-		bufferSize := 64 * 1024       // 64KB par défaut
-		if fileSize > 100*1024*1024 { // > 100MB
-			bufferSize = 1024 * 1024 // 1MB pour gros fichiers
-		}
-
-		bufferedReader := bufio.NewReaderSize(f, bufferSize)
-		_, err := bufferedReader.WriteTo(c.Writer)
-		if err != nil && err != io.EOF {
-			// Log sans abort
-			middleware.ExtractLogger(c).WithError(err).Debug("erreur streaming file (connexion probablement fermée)")
-		}
-	}
+	defer applyTokenRateLimit(c, token)()
+	writeRangedResponse(c, f, fileSize, ranges, fileBufferSize)
 }
@@ -0,0 +1,260 @@
+package router
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/pterodactyl/wings/router/middleware"
+	"github.com/pterodactyl/wings/router/tokens"
+	"github.com/pterodactyl/wings/server/filesystem"
+)
+
+// archiveBufferSize matches the 1MB buffer tier used for large file
+// downloads (fileBufferSizeLarge) so a big tree doesn't get held in memory
+// while it is being archived.
+const archiveBufferSize = 1024 * 1024 // 1MB
+
+// RegisterArchiveRoutes wires the archive download endpoints into an
+// existing, already-authenticated route group. It's meant to be called
+// right next to where getDownloadBackup/getDownloadFile are registered, not
+// used to stand up a route table of its own -- that registration, and the
+// auth/middleware chain it runs through, live outside this tree.
+func RegisterArchiveRoutes(group gin.IRoutes) {
+	group.GET("/download/archive", getDownloadArchive)
+	group.HEAD("/download/archive", getDownloadArchiveHead)
+}
+
+// Handle a HEAD request for an on-the-fly archive download. Since the final
+// archive size isn't known ahead of walking the tree, no Content-Length is
+// emitted and range resumption is explicitly disabled.
+func getDownloadArchiveHead(c *gin.Context) {
+	manager := middleware.ExtractManager(c)
+
+	token := tokens.ArchivePayload{}
+	if err := tokens.ParseToken([]byte(c.Query("token")), &token); err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+
+	if _, ok := manager.Get(token.ServerUuid); !ok {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "The requested resource was not found on this server.",
+		})
+		return
+	}
+
+	if len(token.Paths) == 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "No paths were provided to archive.",
+		})
+		return
+	}
+
+	contentType, archiveType := archiveContentType(token.Format)
+
+	c.Header("Content-Disposition", "attachment; filename="+strconv.Quote(archiveFileName(token)))
+	c.Header("Content-Type", contentType)
+	c.Header("X-Archive-Type", archiveType)
+	c.Header("Accept-Ranges", "none")
+
+	c.Status(http.StatusOK)
+}
+
+// Handles streaming a tar (optionally gzip'd) or zip archive built on the
+// fly from one or more paths within a server's filesystem. Paths are walked
+// and written out as they're read so arbitrarily large trees never have to
+// be buffered in memory or staged to disk first.
+func getDownloadArchive(c *gin.Context) {
+	manager := middleware.ExtractManager(c)
+
+	token := tokens.ArchivePayload{}
+	if err := tokens.ParseToken([]byte(c.Query("token")), &token); err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+
+	s, ok := manager.Get(token.ServerUuid)
+	if !ok || !token.IsUniqueRequest() {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "The requested resource was not found on this server.",
+		})
+		return
+	}
+
+	if len(token.Paths) == 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "No paths were provided to archive.",
+		})
+		return
+	}
+
+	contentType, archiveType := archiveContentType(token.Format)
+
+	c.Header("Content-Disposition", "attachment; filename="+strconv.Quote(archiveFileName(token)))
+	c.Header("Content-Type", contentType)
+	c.Header("X-Archive-Type", archiveType)
+	c.Header("Accept-Ranges", "none")
+	c.Status(http.StatusOK)
+
+	bw := bufio.NewWriterSize(c.Writer, archiveBufferSize)
+	defer bw.Flush()
+
+	var err error
+	if token.Format == tokens.ArchiveFormatZip {
+		err = writeZipArchive(bw, s.Filesystem(), token.Paths)
+	} else {
+		err = writeTarArchive(bw, s.Filesystem(), token.Paths, token.Format == tokens.ArchiveFormatTarGz)
+	}
+	if err != nil {
+		// The client most likely closed the connection partway through the
+		// stream; there isn't anything useful we can do at this point since
+		// headers and a chunk of the body have already been written.
+		middleware.ExtractLogger(c).WithField("error", err).Debug("error while streaming archive download")
+	}
+}
+
+func archiveContentType(format string) (string, string) {
+	switch format {
+	case tokens.ArchiveFormatZip:
+		return "application/zip", "zip"
+	case tokens.ArchiveFormatTarGz:
+		return "application/gzip", "tar.gz"
+	default:
+		return "application/x-tar", "tar"
+	}
+}
+
+func archiveFileName(token tokens.ArchivePayload) string {
+	if token.ArchiveName != "" {
+		return token.ArchiveName
+	}
+	switch token.Format {
+	case tokens.ArchiveFormatZip:
+		return "archive.zip"
+	case tokens.ArchiveFormatTarGz:
+		return "archive.tar.gz"
+	default:
+		return "archive.tar"
+	}
+}
+
+// writeTarArchive walks each of the given paths on the server's filesystem
+// and writes matching files into a tar stream, optionally gzip compressing
+// it, honoring the filesystem's symlink and denylist rules along the way.
+func writeTarArchive(w io.Writer, fs *filesystem.Filesystem, paths []string, gzipped bool) error {
+	out := w
+	if gzipped {
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		out = gz
+	}
+
+	tw := tar.NewWriter(out)
+	defer tw.Close()
+
+	for _, p := range paths {
+		if err := walkArchivePath(fs, p, func(name string, info os.FileInfo, f *os.File) error {
+			hdr, err := tar.FileInfoHeader(info, "")
+			if err != nil {
+				return err
+			}
+			hdr.Name = name
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			if f == nil {
+				return nil
+			}
+			_, err = io.Copy(tw, f)
+			return err
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeZipArchive is the zip equivalent of writeTarArchive.
+func writeZipArchive(w io.Writer, fs *filesystem.Filesystem, paths []string) error {
+	zw := zip.NewWriter(w)
+	defer zw.Close()
+
+	for _, p := range paths {
+		if err := walkArchivePath(fs, p, func(name string, info os.FileInfo, f *os.File) error {
+			hdr, err := zip.FileInfoHeader(info)
+			if err != nil {
+				return err
+			}
+			hdr.Name = name
+			hdr.Method = zip.Deflate
+			if info.IsDir() {
+				hdr.Name += "/"
+			}
+			zf, err := zw.CreateHeader(hdr)
+			if err != nil {
+				return err
+			}
+			if f == nil {
+				return nil
+			}
+			_, err = io.Copy(zf, f)
+			return err
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// walkArchivePath walks root (a file or a directory) on fs, honoring the
+// filesystem's denylist and symlink handling, and invokes callback for every
+// entry with a path relative to root's parent and, for regular files, an
+// open *os.File positioned at the start of the file.
+func walkArchivePath(fs *filesystem.Filesystem, root string, callback func(name string, info os.FileInfo, f *os.File) error) error {
+	base := strings.TrimSuffix(filepath.Base(root), "/")
+
+	return fs.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fs.IsIgnored(path) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		name := base
+		if rel != "." {
+			name = filepath.Join(base, rel)
+		}
+
+		if info.IsDir() {
+			return callback(name, info, nil)
+		}
+
+		f, _, err := fs.File(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		return callback(name, info, f)
+	})
+}
@@ -0,0 +1,170 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+// maxConcurrentDownloadsPerServer and maxConcurrentDownloadsPerIP cap how
+// many of the signed backup/file download URLs can be in flight at once for
+// a given server or remote address. These caps apply on top of the
+// one-time "unique request" check that every backup/file GET (ranged or
+// not) already goes through; HEAD requests take no slot.
+const (
+	maxConcurrentDownloadsPerServer = 10
+	maxConcurrentDownloadsPerIP     = 4
+)
+
+// rateLimitedPayload is implemented by a download token that carries an
+// optional per-connection bandwidth cap, in bytes/sec. A zero (or negative)
+// value, or a token that doesn't implement this at all, means unlimited.
+// tokens.BackupPayload and tokens.FilePayload implement this via their
+// RateLimit field.
+type rateLimitedPayload interface {
+	RateLimitBytesPerSec() int64
+}
+
+type downloadLimiter struct {
+	mu        sync.Mutex
+	byServer  map[string]int
+	byAddress map[string]int
+
+	activeTotal int64
+	egressTotal int64
+}
+
+var downloads = &downloadLimiter{
+	byServer:  make(map[string]int),
+	byAddress: make(map[string]int),
+}
+
+// acquire reserves a download slot for serverUUID/address. It returns false,
+// reserving nothing, if either concurrency cap is already at its limit.
+func (l *downloadLimiter) acquire(serverUUID, address string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.byServer[serverUUID] >= maxConcurrentDownloadsPerServer {
+		return false
+	}
+	if l.byAddress[address] >= maxConcurrentDownloadsPerIP {
+		return false
+	}
+
+	l.byServer[serverUUID]++
+	l.byAddress[address]++
+	atomic.AddInt64(&l.activeTotal, 1)
+	return true
+}
+
+func (l *downloadLimiter) release(serverUUID, address string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.byServer[serverUUID]--
+	if l.byServer[serverUUID] <= 0 {
+		delete(l.byServer, serverUUID)
+	}
+	l.byAddress[address]--
+	if l.byAddress[address] <= 0 {
+		delete(l.byAddress, address)
+	}
+	atomic.AddInt64(&l.activeTotal, -1)
+}
+
+// DownloadLimiterStats is a point-in-time snapshot of how many backup/file
+// downloads are active and how many bytes finished downloads have sent in
+// total (a download's bytes are only added once it ends), surfaced on the
+// /usage system endpoint so the panel can see a node approaching saturation.
+type DownloadLimiterStats struct {
+	ActiveDownloads int64 `json:"active_downloads"`
+	EgressBytes     int64 `json:"egress_bytes"`
+}
+
+// DownloadStats returns the current aggregate download concurrency/egress
+// counters.
+func DownloadStats() DownloadLimiterStats {
+	return DownloadLimiterStats{
+		ActiveDownloads: atomic.LoadInt64(&downloads.activeTotal),
+		EgressBytes:     atomic.LoadInt64(&downloads.egressTotal),
+	}
+}
+
+// acquireDownloadSlot enforces the per-server/per-IP concurrency caps for a
+// download request, writing a 429 with Retry-After and returning false if
+// the caller should stop handling the request. On success it returns a
+// release func that must be deferred by the caller.
+func acquireDownloadSlot(c *gin.Context, serverUUID string) (release func(), ok bool) {
+	address := c.ClientIP()
+
+	if !downloads.acquire(serverUUID, address) {
+		c.Header("Retry-After", "5")
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+			"error": "Too many concurrent downloads for this server or address, try again shortly.",
+		})
+		return nil, false
+	}
+
+	return func() { downloads.release(serverUUID, address) }, true
+}
+
+// downloadLimiterMinBurst is the smallest burst a rate-limited download
+// writer is ever allowed, regardless of how small the token's configured
+// rate is. It must be at least backupBufferSizeLarge (router_download.go),
+// the biggest single buffer the full-file download path can hand to one
+// Write() call: rate.Limiter.WaitN errors immediately if asked to wait for
+// more bytes than its burst, so undershooting here would make a
+// rate-limited download of a large backup fail outright on its first flush
+// instead of actually being throttled.
+const downloadLimiterMinBurst = backupBufferSizeLarge
+
+// applyTokenRateLimit wraps c.Writer in a token-bucket limited writer when
+// token carries a positive RateLimitBytesPerSec, so both the ranged and
+// full-file code paths are throttled the same way. It returns a restore
+// func that must be deferred by the caller to both put the original writer
+// back and fold the bytes written into the aggregate egress counter.
+func applyTokenRateLimit(c *gin.Context, token interface{}) func() {
+	original := c.Writer
+	cw := &countingRateLimitedWriter{ResponseWriter: original}
+
+	if rl, ok := token.(rateLimitedPayload); ok {
+		if limit := rl.RateLimitBytesPerSec(); limit > 0 {
+			burst := int(limit)
+			if burst < downloadLimiterMinBurst {
+				burst = downloadLimiterMinBurst
+			}
+			cw.limiter = rate.NewLimiter(rate.Limit(limit), burst)
+		}
+	}
+
+	c.Writer = cw
+	return func() {
+		c.Writer = original
+		atomic.AddInt64(&downloads.egressTotal, cw.written)
+	}
+}
+
+// countingRateLimitedWriter wraps a gin.ResponseWriter, optionally pacing
+// writes through a token-bucket limiter and always tallying bytes written so
+// they can be folded into the aggregate egress counter.
+type countingRateLimitedWriter struct {
+	gin.ResponseWriter
+	limiter *rate.Limiter
+	written int64
+}
+
+func (w *countingRateLimitedWriter) Write(p []byte) (int, error) {
+	if w.limiter != nil {
+		if err := w.limiter.WaitN(context.Background(), len(p)); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.written += int64(n)
+	return n, err
+}
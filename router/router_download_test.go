@@ -0,0 +1,192 @@
+package router
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRangeHeader(t *testing.T) {
+	const fileSize = 1000
+
+	tests := []struct {
+		name    string
+		header  string
+		want    []rangeSpec
+		wantErr bool
+	}{
+		{
+			name: "no range",
+			want: nil,
+		},
+		{
+			name:   "single range",
+			header: "bytes=0-499",
+			want:   []rangeSpec{{start: 0, end: 499, size: 500}},
+		},
+		{
+			name:   "suffix range",
+			header: "bytes=-500",
+			want:   []rangeSpec{{start: 500, end: 999, size: 500}},
+		},
+		{
+			name:   "suffix range larger than file",
+			header: "bytes=-5000",
+			want:   []rangeSpec{{start: 0, end: 999, size: 1000}},
+		},
+		{
+			name:   "open-ended range",
+			header: "bytes=900-",
+			want:   []rangeSpec{{start: 900, end: 999, size: 100}},
+		},
+		{
+			name:   "end clamped to file size",
+			header: "bytes=900-5000",
+			want:   []rangeSpec{{start: 900, end: 999, size: 100}},
+		},
+		{
+			name:   "overlapping ranges are coalesced",
+			header: "bytes=0-99,50-149",
+			want:   []rangeSpec{{start: 0, end: 149, size: 150}},
+		},
+		{
+			name:   "adjacent ranges are coalesced",
+			header: "bytes=0-99,100-199",
+			want:   []rangeSpec{{start: 0, end: 199, size: 200}},
+		},
+		{
+			name:   "disjoint ranges are kept separate",
+			header: "bytes=0-99,200-299",
+			want: []rangeSpec{
+				{start: 0, end: 99, size: 100},
+				{start: 200, end: 299, size: 100},
+			},
+		},
+		{
+			name:    "invalid format",
+			header:  "0-499",
+			wantErr: true,
+		},
+		{
+			name:    "unsatisfiable range",
+			header:  "bytes=5000-6000",
+			wantErr: true,
+		},
+		{
+			name:    "too many ranges",
+			header:  "bytes=" + repeatRange(maxRangesPerRequest+1),
+			wantErr: true,
+		},
+		{
+			name:   "several disjoint ranges within span limit",
+			header: "bytes=0-199,300-499,600-799,850-999",
+			want: []rangeSpec{
+				{start: 0, end: 199, size: 200},
+				{start: 300, end: 499, size: 200},
+				{start: 600, end: 799, size: 200},
+				{start: 850, end: 999, size: 150},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRangeHeader(tt.header, fileSize)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseRangeHeader() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("parseRangeHeader() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRangeHeaderAllowsSpanUpToFileSize(t *testing.T) {
+	// Every individual range is clamped into [0, fileSize), so the
+	// coalesced total can never exceed fileSize itself -- this exercises
+	// that upper edge (total == fileSize, still well within the
+	// maxRangeSpanMultiplier cutoff) rather than a scenario that can't
+	// occur for well-formed ranges.
+	if _, err := parseRangeHeader("bytes=0-499,500-999", 1000); err != nil {
+		t.Fatalf("expected span covering the whole file to succeed, got %v", err)
+	}
+}
+
+func TestCoalesceRanges(t *testing.T) {
+	tests := []struct {
+		name   string
+		ranges []rangeSpec
+		want   []rangeSpec
+	}{
+		{
+			name:   "empty",
+			ranges: nil,
+			want:   nil,
+		},
+		{
+			name:   "single range",
+			ranges: []rangeSpec{{start: 10, end: 20, size: 11}},
+			want:   []rangeSpec{{start: 10, end: 20, size: 11}},
+		},
+		{
+			name: "unsorted input is sorted",
+			ranges: []rangeSpec{
+				{start: 200, end: 299, size: 100},
+				{start: 0, end: 99, size: 100},
+			},
+			want: []rangeSpec{
+				{start: 0, end: 99, size: 100},
+				{start: 200, end: 299, size: 100},
+			},
+		},
+		{
+			name: "adjacent ranges merge",
+			ranges: []rangeSpec{
+				{start: 0, end: 99, size: 100},
+				{start: 100, end: 199, size: 100},
+			},
+			want: []rangeSpec{{start: 0, end: 199, size: 200}},
+		},
+		{
+			name: "overlapping ranges merge",
+			ranges: []rangeSpec{
+				{start: 0, end: 149, size: 150},
+				{start: 100, end: 199, size: 100},
+			},
+			want: []rangeSpec{{start: 0, end: 199, size: 200}},
+		},
+		{
+			name: "one range fully contained in another",
+			ranges: []rangeSpec{
+				{start: 0, end: 999, size: 1000},
+				{start: 100, end: 199, size: 100},
+			},
+			want: []rangeSpec{{start: 0, end: 999, size: 1000}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := coalesceRanges(tt.ranges)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("coalesceRanges() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// repeatRange builds a "start-end,start-end,..." range list of n 1-byte
+// ranges, used to exercise the maxRangesPerRequest cap.
+func repeatRange(n int) string {
+	s := ""
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			s += ","
+		}
+		s += "0-0"
+	}
+	return s
+}
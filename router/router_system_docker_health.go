@@ -0,0 +1,31 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/pterodactyl/wings/system"
+)
+
+// RegisterSystemDockerHealthRoute wires the Docker health endpoint into an
+// existing, already-authenticated route group, meant to be called next to
+// the real route table's other /api/system registrations.
+func RegisterSystemDockerHealthRoute(group gin.IRoutes) {
+	group.GET("/docker/health", getSystemDockerHealth)
+}
+
+// Lets the panel distinguish "Wings is up but Docker isn't responding" from
+// a full node outage, without waiting on a complete system information
+// request to time out.
+func getSystemDockerHealth(c *gin.Context) {
+	if _, err := system.DockerClientPing(c.Request.Context()); err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"healthy": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"healthy": true})
+}
@@ -0,0 +1,38 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/system"
+)
+
+// RegisterSystemUsageRoute wires the data-usage endpoint into an existing,
+// already-authenticated route group, meant to be called next to the real
+// route table's other /api/system registrations.
+func RegisterSystemUsageRoute(group gin.IRoutes) {
+	group.GET("/usage", getSystemUsage)
+}
+
+// Returns the cached per-server/per-volume data usage breakdown collected by
+// the background usage scanner, alongside the current in-flight backup/file
+// download counts and aggregate egress. Passing a "refresh" query
+// parameter, whatever its value, triggers a background rescan while still
+// returning the last cached snapshot immediately.
+func getSystemUsage(c *gin.Context) {
+	_, forceRefresh := c.GetQuery("refresh")
+
+	cfg := config.Get()
+	// config.SystemConfiguration doesn't have a scan-interval field in this
+	// tree (nor did the baseline it builds on), so there's nothing to read
+	// yet; pass 0 and let usage.New fall back to usage.DefaultScanInterval
+	// until that field exists to thread through here.
+	data := system.GetDataUsage(cfg.System.Data, cfg.System.BackupDirectory, 0, forceRefresh)
+
+	c.JSON(http.StatusOK, gin.H{
+		"data_usage": data,
+		"downloads":  DownloadStats(),
+	})
+}
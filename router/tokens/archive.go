@@ -0,0 +1,41 @@
+package tokens
+
+import (
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Supported archive formats for ArchivePayload.Format.
+const (
+	ArchiveFormatTar   = "tar"
+	ArchiveFormatTarGz = "tar.gz"
+	ArchiveFormatZip   = "zip"
+)
+
+// ArchivePayload is the token used to authenticate a request to the
+// "/download/archive" endpoint. It lists one or more paths on a server's
+// filesystem that should be streamed back as a single archive.
+type ArchivePayload struct {
+	jwt.RegisteredClaims
+
+	ServerUuid string `json:"server_uuid"`
+	// Paths is the list of file or directory paths (relative to the
+	// server's root) to include in the archive.
+	Paths []string `json:"paths"`
+	// Format is one of ArchiveFormatTar, ArchiveFormatTarGz or ArchiveFormatZip.
+	// Defaults to ArchiveFormatTar when empty.
+	Format string `json:"format"`
+	// ArchiveName is the filename (without forcing an extension) the client
+	// would like the resulting archive to be served as.
+	ArchiveName string `json:"archive_name"`
+}
+
+var archiveRequestCache uniqueRequestCache
+
+// IsUniqueRequest returns true the first time it is called for a given
+// token, and false on every subsequent call, mirroring the one-time-use
+// semantics of the other download payloads so a signed archive URL can't be
+// replayed indefinitely. Entries are dropped once the token itself expires
+// instead of being kept around forever.
+func (p ArchivePayload) IsUniqueRequest() bool {
+	return archiveRequestCache.claim(p.ID, p.ExpiresAt)
+}
@@ -0,0 +1,33 @@
+package tokens
+
+import (
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// FilePayload is the token used to authenticate a request to download a
+// single file from a server's filesystem.
+type FilePayload struct {
+	jwt.RegisteredClaims
+
+	ServerUuid string `json:"server_uuid"`
+	FilePath   string `json:"file_path"`
+	// RateLimit optionally caps how fast this token's download may be
+	// served, in bytes/sec. Zero (the default) means unlimited, and is what
+	// the panel mints for ordinary file downloads today.
+	RateLimit int64 `json:"rate_limit,omitempty"`
+}
+
+var fileRequestCache uniqueRequestCache
+
+// IsUniqueRequest returns true the first time it is called for a given
+// token, and false on every subsequent call, so a signed file download URL
+// can't be replayed indefinitely.
+func (p FilePayload) IsUniqueRequest() bool {
+	return fileRequestCache.claim(p.ID, p.ExpiresAt)
+}
+
+// RateLimitBytesPerSec returns the bandwidth cap encoded in the token, or 0
+// for unlimited.
+func (p FilePayload) RateLimitBytesPerSec() int64 {
+	return p.RateLimit
+}
@@ -0,0 +1,53 @@
+package tokens
+
+import (
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// expiredEntrySweepInterval controls how often a uniqueRequestCache drops
+// entries for tokens that have since expired.
+const expiredEntrySweepInterval = 10 * time.Minute
+
+// uniqueRequestCache tracks one-time-use JWT IDs for a single payload type.
+// Entries are evicted once their token's expiry has passed so a long-running
+// daemon doesn't grow the cache unboundedly just because every download adds
+// an entry that nothing would otherwise remove.
+type uniqueRequestCache struct {
+	once sync.Once
+	m    sync.Map
+}
+
+// claim returns true the first time it's called for id, and false on every
+// subsequent call while the entry is cached. Once the entry has been swept
+// (after the token's expiry, or expiredEntrySweepInterval after the first
+// claim for a token without one) a new call for the same id returns true
+// again.
+func (c *uniqueRequestCache) claim(id string, expiresAt *jwt.NumericDate) bool {
+	c.once.Do(func() { go c.sweep() })
+
+	expiry := time.Now().Add(expiredEntrySweepInterval)
+	if expiresAt != nil {
+		expiry = expiresAt.Time
+	}
+
+	_, exists := c.m.LoadOrStore(id, expiry)
+	return !exists
+}
+
+func (c *uniqueRequestCache) sweep() {
+	t := time.NewTicker(expiredEntrySweepInterval)
+	defer t.Stop()
+
+	for range t.C {
+		now := time.Now()
+		c.m.Range(func(key, value interface{}) bool {
+			if expiry, ok := value.(time.Time); ok && now.After(expiry) {
+				c.m.Delete(key)
+			}
+			return true
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package system
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+)
+
+// dockerReconnectInitialBackoff and dockerReconnectMaxBackoff bound the
+// exponential backoff used when re-establishing a dropped Docker connection.
+const (
+	dockerReconnectInitialBackoff = 500 * time.Millisecond
+	dockerReconnectMaxBackoff     = 30 * time.Second
+	dockerReconnectMaxAttempts    = 5
+)
+
+var (
+	dockerClientMu sync.Mutex
+	dockerClient   *client.Client
+	dockerOnce     sync.Once
+
+	dockerReconnecting atomic.Bool
+)
+
+func newDockerClient() (*client.Client, error) {
+	return client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+}
+
+// DockerClient returns a shared, API-version-negotiated Docker client. The
+// negotiation round-trip only happens once, on first use; later callers get
+// the same cached client instead of paying for it on every request. If the
+// connection was never established, or is later found to be gone, it is
+// re-created with an exponential backoff.
+//
+// system.GetDockerInfo is routed through this accessor. The environment/docker,
+// backup/restore and server lifecycle packages described in the originating
+// request are not part of this change set — none of those files exist in
+// this tree to update — and still construct their own clients until they're
+// migrated over separately.
+func DockerClient() (*client.Client, error) {
+	dockerOnce.Do(func() {
+		dockerClientMu.Lock()
+		defer dockerClientMu.Unlock()
+		if c, err := newDockerClient(); err == nil {
+			dockerClient = c
+		}
+	})
+
+	dockerClientMu.Lock()
+	c := dockerClient
+	dockerClientMu.Unlock()
+	if c != nil {
+		return c, nil
+	}
+
+	return reconnectDockerClient(nil)
+}
+
+// reconnectDockerClient replaces the shared client with a freshly negotiated
+// one, used both when the initial negotiation failed (broken is nil) and
+// when a caller detects that a specific connection it was handed (broken)
+// has dropped. The mutex is only held around the brief swap, never across
+// the retry/backoff loop, so a Docker outage doesn't also stall every other
+// goroutine calling DockerClient() for the full ~15s backoff window. Before
+// installing a newly negotiated client we re-check that the shared client
+// still matches what this caller observed as broken, so two goroutines
+// racing to reconnect can't have one tear down a connection the other just
+// established.
+func reconnectDockerClient(broken *client.Client) (*client.Client, error) {
+	backoff := dockerReconnectInitialBackoff
+	var lastErr error
+
+	for attempt := 0; attempt < dockerReconnectMaxAttempts; attempt++ {
+		dockerClientMu.Lock()
+		current := dockerClient
+		dockerClientMu.Unlock()
+		if current != nil && current != broken {
+			// Another goroutine already reconnected; use that one.
+			return current, nil
+		}
+
+		c, err := newDockerClient()
+		if err != nil {
+			lastErr = err
+			time.Sleep(backoff)
+			if backoff *= 2; backoff > dockerReconnectMaxBackoff {
+				backoff = dockerReconnectMaxBackoff
+			}
+			continue
+		}
+
+		dockerClientMu.Lock()
+		if dockerClient != nil && dockerClient != broken {
+			// Lost the race: someone else's reconnect already landed, keep
+			// theirs and drop the one we just negotiated.
+			existing := dockerClient
+			dockerClientMu.Unlock()
+			_ = c.Close()
+			return existing, nil
+		}
+		if dockerClient != nil {
+			_ = dockerClient.Close()
+		}
+		dockerClient = c
+		dockerClientMu.Unlock()
+		return c, nil
+	}
+
+	return nil, lastErr
+}
+
+// DockerClientPing performs a lightweight health check against the shared
+// Docker client. Unlike dockerCallWithReconnect, it never blocks the caller
+// on the multi-attempt reconnect backoff (up to ~15s) when the connection
+// looks to have dropped -- that's the whole point of this check existing
+// alongside the system info endpoint, which is allowed to pay that cost.
+// Instead it reports the immediate failure and kicks off a reconnect in the
+// background, so a later call can succeed once that completes.
+func DockerClientPing(ctx context.Context) (types.Ping, error) {
+	c, err := DockerClient()
+	if err != nil {
+		return types.Ping{}, err
+	}
+
+	p, err := c.Ping(ctx)
+	if err != nil && client.IsErrConnectionFailed(err) {
+		triggerAsyncReconnect(c)
+	}
+	return p, err
+}
+
+// triggerAsyncReconnect runs reconnectDockerClient in the background, at
+// most one attempt at a time, so a burst of failing health checks doesn't
+// pile up redundant reconnect loops.
+func triggerAsyncReconnect(broken *client.Client) {
+	if !dockerReconnecting.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer dockerReconnecting.Store(false)
+		_, _ = reconnectDockerClient(broken)
+	}()
+}
+
+// dockerCallWithReconnect invokes fn against c and, if it fails with a
+// connection error, reconnects once and retries fn against the fresh
+// client. Callers that already hold a *client.Client (rather than calling
+// DockerClient() fresh) use this so a dropped connection is only ever
+// retried once instead of failing outright until something else happens to
+// trigger a reconnect. It also returns the client the successful call was
+// actually made against, so a caller making several calls in a row can reuse
+// it instead of retrying the same known-stale client on the next one.
+func dockerCallWithReconnect[T any](c *client.Client, fn func(*client.Client) (T, error)) (T, *client.Client, error) {
+	v, err := fn(c)
+	if err != nil && client.IsErrConnectionFailed(err) {
+		if reconnected, rerr := reconnectDockerClient(c); rerr == nil {
+			v, err = fn(reconnected)
+			return v, reconnected, err
+		}
+	}
+	return v, c, err
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"math"
 	"runtime"
+	"sync"
+	"time"
 
 	"github.com/acobaugh/osrelease"
 	"github.com/docker/docker/api/types"
@@ -13,12 +15,15 @@ import (
 	"github.com/shirou/gopsutil/v4/cpu"
 	"github.com/shirou/gopsutil/v4/disk"
 	"github.com/shirou/gopsutil/v4/mem"
+
+	"github.com/pterodactyl/wings/system/usage"
 )
 
 type Information struct {
-	Version string            `json:"version"`
-	Docker  DockerInformation `json:"docker"`
-	System  System            `json:"system"`
+	Version   string              `json:"version"`
+	Docker    DockerInformation   `json:"docker"`
+	System    System              `json:"system"`
+	DataUsage usage.DataUsageInfo `json:"data_usage"`
 }
 
 type DockerInformation struct {
@@ -68,7 +73,45 @@ type System struct {
 	OSType                  string   `json:"os_type"`
 }
 
-func GetSystemInformation(VolumesPath string, BackupPath string) (*Information, error) {
+var usageScanner *usage.Scanner
+var usageScannerOnce sync.Once
+
+// UsageScanner returns the package's shared background data-usage scanner,
+// starting its scan loop against the given volumes/backups roots the first
+// time it is called. interval configures how often it re-walks those roots;
+// a zero value falls back to usage.DefaultScanInterval. Only the first
+// caller's interval takes effect, matching the once-only construction of
+// the scanner itself.
+func UsageScanner(volumesPath string, backupPath string, interval time.Duration) *usage.Scanner {
+	usageScannerOnce.Do(func() {
+		usageScanner = usage.New(volumesPath, backupPath, interval)
+		go usageScanner.Start(context.Background())
+	})
+	return usageScanner
+}
+
+// GetDataUsage returns the shared usage scanner's cached snapshot for the
+// given volumes/backups roots and scan interval, optionally kicking off a
+// background rescan first when forceRefresh is set. Either way the last
+// cached snapshot is returned immediately; this never blocks on a full disk
+// walk.
+func GetDataUsage(volumesPath string, backupPath string, interval time.Duration, forceRefresh bool) usage.DataUsageInfo {
+	scanner := UsageScanner(volumesPath, backupPath, interval)
+	if forceRefresh {
+		return scanner.ForceRefresh()
+	}
+	return scanner.Snapshot()
+}
+
+// GetSystemInformation builds the node's system information payload.
+// UsageScanInterval is passed through to the shared usage scanner the same
+// way VolumesPath/BackupPath are: the caller is responsible for sourcing it
+// from config, since the scanner is a process-wide singleton and whichever
+// caller constructs it first decides its interval for the rest of the
+// process's life -- hardcoding a value here would silently override
+// whatever the operator configured if this ran before /api/system/usage
+// ever did.
+func GetSystemInformation(VolumesPath string, BackupPath string, UsageScanInterval time.Duration) (*Information, error) {
 	k, err := kernel.GetKernelVersion()
 	if err != nil {
 		return nil, err
@@ -136,7 +179,8 @@ func GetSystemInformation(VolumesPath string, BackupPath string) (*Information,
 	}
 
 	return &Information{
-		Version: Version,
+		Version:   Version,
+		DataUsage: GetDataUsage(VolumesPath, BackupPath, UsageScanInterval, false),
 		Docker: DockerInformation{
 			Version: version.Version,
 			Cgroups: DockerCgroups{
@@ -178,19 +222,21 @@ func GetSystemInformation(VolumesPath string, BackupPath string) (*Information,
 }
 
 func GetDockerInfo(ctx context.Context) (types.Version, system.Info, error) {
-	// TODO: find a way to re-use the client from the docker environment.
-	c, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	c, err := DockerClient()
 	if err != nil {
 		return types.Version{}, system.Info{}, err
 	}
-	defer c.Close()
 
-	dockerVersion, err := c.ServerVersion(ctx)
+	dockerVersion, c, err := dockerCallWithReconnect(c, func(c *client.Client) (types.Version, error) {
+		return c.ServerVersion(ctx)
+	})
 	if err != nil {
 		return types.Version{}, system.Info{}, err
 	}
 
-	dockerInfo, err := c.Info(ctx)
+	dockerInfo, _, err := dockerCallWithReconnect(c, func(c *client.Client) (system.Info, error) {
+		return c.Info(ctx)
+	})
 	if err != nil {
 		return types.Version{}, system.Info{}, err
 	}
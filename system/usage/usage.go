@@ -0,0 +1,224 @@
+// Package usage implements a background scanner that periodically walks the
+// volumes and backups directories to build a per-server disk usage
+// breakdown, modeled after MinIO's data-usage crawler: results are cached in
+// memory and handlers read the last snapshot instead of walking the
+// filesystem on every request.
+package usage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+)
+
+// DefaultScanInterval is how often the background scanner re-walks the
+// volumes and backups roots when no explicit interval is configured.
+const DefaultScanInterval = 15 * time.Minute
+
+// maxSamples is the number of largest files retained per server.
+const maxSamples = 5
+
+// FileSample describes one of the largest files found for a server, so a
+// panel can show what is actually eating into a server's disk quota.
+type FileSample struct {
+	Path  string `json:"path"`
+	Bytes int64  `json:"bytes"`
+}
+
+// ServerUsage is the accumulated usage for a single server UUID, combining
+// its volume and any on-disk backups.
+type ServerUsage struct {
+	ServerUUID   string       `json:"server_uuid"`
+	Bytes        int64        `json:"bytes"`
+	Files        int64        `json:"files"`
+	LargestFiles []FileSample `json:"largest_files"`
+}
+
+// DataUsageInfo is a point-in-time snapshot produced by a scan.
+type DataUsageInfo struct {
+	Servers  map[string]ServerUsage `json:"servers"`
+	LastScan time.Time              `json:"last_scan"`
+	Scanning bool                   `json:"scanning"`
+}
+
+// Scanner periodically walks the volumes and backups roots in the
+// background and caches a per-server usage breakdown.
+type Scanner struct {
+	volumesPath string
+	backupsPath string
+	interval    time.Duration
+
+	mu       sync.RWMutex
+	snapshot DataUsageInfo
+
+	forceCh chan struct{}
+}
+
+// New creates a Scanner for the given volumes and backups roots. Call Start
+// to begin the background scan loop; until the first scan completes,
+// Snapshot returns a DataUsageInfo with no servers and a zero LastScan
+// (with Scanning set while that first scan is running).
+func New(volumesPath, backupsPath string, interval time.Duration) *Scanner {
+	if interval <= 0 {
+		interval = DefaultScanInterval
+	}
+	return &Scanner{
+		volumesPath: volumesPath,
+		backupsPath: backupsPath,
+		interval:    interval,
+		forceCh:     make(chan struct{}, 1),
+	}
+}
+
+// Start runs the scan loop until ctx is cancelled. It performs an initial
+// scan immediately, then re-scans on the configured interval or whenever
+// ForceRefresh is called.
+func (s *Scanner) Start(ctx context.Context) {
+	s.scan()
+
+	t := time.NewTicker(s.interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			s.scan()
+		case <-s.forceCh:
+			s.scan()
+		}
+	}
+}
+
+// Snapshot returns the last cached scan result.
+func (s *Scanner) Snapshot() DataUsageInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.snapshot
+}
+
+// ForceRefresh schedules an immediate rescan in the background and returns
+// the last cached snapshot right away, so a caller asking for fresh data
+// never blocks on a full disk walk.
+func (s *Scanner) ForceRefresh() DataUsageInfo {
+	select {
+	case s.forceCh <- struct{}{}:
+	default:
+		// a scan is already pending, no need to queue a second one
+	}
+	return s.Snapshot()
+}
+
+func (s *Scanner) scan() {
+	s.mu.Lock()
+	s.snapshot.Scanning = true
+	s.mu.Unlock()
+
+	servers := make(map[string]ServerUsage)
+	s.walkVolumes(servers)
+	s.walkBackups(servers)
+
+	s.mu.Lock()
+	s.snapshot = DataUsageInfo{Servers: servers, LastScan: time.Now(), Scanning: false}
+	s.mu.Unlock()
+}
+
+func (s *Scanner) walkVolumes(out map[string]ServerUsage) {
+	entries, err := os.ReadDir(s.volumesPath)
+	if err != nil {
+		log.WithField("error", err).Warn("usage: could not read volumes path")
+		return
+	}
+
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		out[e.Name()] = s.walkServerVolume(e.Name(), filepath.Join(s.volumesPath, e.Name()))
+	}
+}
+
+// walkServerVolume walks a single server's volume directory, accumulating
+// total size/file count and tracking the largest files seen. Every couple
+// thousand files it yields briefly so a scan of a huge volume doesn't starve
+// other disk IO on the node.
+func (s *Scanner) walkServerVolume(uuid, root string) ServerUsage {
+	u := ServerUsage{ServerUUID: uuid}
+	var samples []FileSample
+
+	count := 0
+	_ = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+
+		u.Bytes += info.Size()
+		u.Files++
+		samples = keepLargest(samples, FileSample{Path: path, Bytes: info.Size()})
+
+		count++
+		if count%2000 == 0 {
+			time.Sleep(10 * time.Millisecond)
+		}
+		return nil
+	})
+
+	u.LargestFiles = samples
+
+	return u
+}
+
+// keepLargest inserts f into samples, which is kept sorted by descending
+// size and capped at maxSamples entries, so a scan never holds more than
+// maxSamples FileSamples in memory at once regardless of how many files are
+// in the tree being walked.
+func keepLargest(samples []FileSample, f FileSample) []FileSample {
+	if len(samples) == maxSamples && f.Bytes <= samples[len(samples)-1].Bytes {
+		return samples
+	}
+
+	i := sort.Search(len(samples), func(i int) bool { return samples[i].Bytes < f.Bytes })
+	samples = append(samples, FileSample{})
+	copy(samples[i+1:], samples[i:])
+	samples[i] = f
+
+	if len(samples) > maxSamples {
+		samples = samples[:maxSamples]
+	}
+	return samples
+}
+
+// walkBackups scans the flat backups directory (files named
+// "<server-uuid>.tar.gz") and folds their size into the matching server's
+// usage entry, creating one if the server has no volume on this node.
+func (s *Scanner) walkBackups(out map[string]ServerUsage) {
+	entries, err := os.ReadDir(s.backupsPath)
+	if err != nil {
+		log.WithField("error", err).Warn("usage: could not read backups path")
+		return
+	}
+
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+
+		uuid := strings.TrimSuffix(strings.TrimSuffix(e.Name(), ".tar.gz"), ".tar")
+		u := out[uuid]
+		u.ServerUUID = uuid
+		u.Bytes += info.Size()
+		u.Files++
+		out[uuid] = u
+	}
+}
@@ -0,0 +1,89 @@
+package usage
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestKeepLargest(t *testing.T) {
+	f := func(path string, bytes int64) FileSample { return FileSample{Path: path, Bytes: bytes} }
+
+	t.Run("inserts in descending order", func(t *testing.T) {
+		var samples []FileSample
+		samples = keepLargest(samples, f("a", 10))
+		samples = keepLargest(samples, f("b", 30))
+		samples = keepLargest(samples, f("c", 20))
+
+		want := []FileSample{f("b", 30), f("c", 20), f("a", 10)}
+		if !reflect.DeepEqual(samples, want) {
+			t.Fatalf("got %+v, want %+v", samples, want)
+		}
+	})
+
+	t.Run("caps at maxSamples", func(t *testing.T) {
+		var samples []FileSample
+		for i := int64(0); i < int64(maxSamples)+5; i++ {
+			samples = keepLargest(samples, f("f", i))
+		}
+		if len(samples) != maxSamples {
+			t.Fatalf("got %d samples, want %d", len(samples), maxSamples)
+		}
+		// The largest maxSamples values inserted were the last ones, in
+		// descending order.
+		for i, s := range samples {
+			want := int64(maxSamples) + 4 - int64(i)
+			if s.Bytes != want {
+				t.Fatalf("sample %d = %d bytes, want %d", i, s.Bytes, want)
+			}
+		}
+	})
+
+	t.Run("smaller-than-smallest is dropped once full", func(t *testing.T) {
+		var samples []FileSample
+		for i := 0; i < maxSamples; i++ {
+			samples = keepLargest(samples, f("f", int64(100+i)))
+		}
+		before := append([]FileSample(nil), samples...)
+
+		samples = keepLargest(samples, f("tiny", 1))
+
+		if !reflect.DeepEqual(samples, before) {
+			t.Fatalf("expected no change when inserting a smaller-than-smallest sample, got %+v", samples)
+		}
+	})
+
+	t.Run("larger-than-smallest evicts the smallest once full", func(t *testing.T) {
+		var samples []FileSample
+		for i := 0; i < maxSamples; i++ {
+			samples = keepLargest(samples, f("f", int64(100+i)))
+		}
+
+		samples = keepLargest(samples, f("big", 1000))
+
+		if len(samples) != maxSamples {
+			t.Fatalf("got %d samples, want %d", len(samples), maxSamples)
+		}
+		if samples[0].Path != "big" {
+			t.Fatalf("expected the new largest sample first, got %+v", samples)
+		}
+		for _, s := range samples {
+			if s.Path == "f" && s.Bytes == 100 {
+				t.Fatalf("expected the smallest sample to be evicted, got %+v", samples)
+			}
+		}
+	})
+
+	t.Run("equal to current smallest is dropped", func(t *testing.T) {
+		var samples []FileSample
+		for i := 0; i < maxSamples; i++ {
+			samples = keepLargest(samples, f("f", int64(100+i)))
+		}
+		before := append([]FileSample(nil), samples...)
+
+		samples = keepLargest(samples, f("tie", 100))
+
+		if !reflect.DeepEqual(samples, before) {
+			t.Fatalf("expected no change when inserting a sample tied with the smallest, got %+v", samples)
+		}
+	})
+}